@@ -0,0 +1,103 @@
+package main
+
+import (
+	"math/rand"
+	"os"
+	"strconv"
+	"time"
+)
+
+var (
+	wakeBackoffEnabled bool
+	wakeBackoffBase    = 250 * time.Millisecond
+	wakeBackoffMax     = 5 * time.Second
+	wakeBackoffFactor  = 1.6
+	wakeBackoffJitter  = 0.2
+	wakeRepeatMagic    = 15 * time.Second
+)
+
+func init() {
+	if ms := os.Getenv("WAKE_BACKOFF_BASE_MS"); ms != "" {
+		if v, err := strconv.Atoi(ms); err == nil {
+			wakeBackoffBase = time.Duration(v) * time.Millisecond
+			wakeBackoffEnabled = true
+		}
+	}
+	if ms := os.Getenv("WAKE_BACKOFF_MAX_MS"); ms != "" {
+		if v, err := strconv.Atoi(ms); err == nil {
+			wakeBackoffMax = time.Duration(v) * time.Millisecond
+			wakeBackoffEnabled = true
+		}
+	}
+	if f := os.Getenv("WAKE_BACKOFF_FACTOR"); f != "" {
+		if v, err := strconv.ParseFloat(f, 64); err == nil {
+			wakeBackoffFactor = v
+			wakeBackoffEnabled = true
+		}
+	}
+	if j := os.Getenv("WAKE_BACKOFF_JITTER"); j != "" {
+		if v, err := strconv.ParseFloat(j, 64); err == nil {
+			wakeBackoffJitter = v
+			wakeBackoffEnabled = true
+		}
+	}
+	if rs := os.Getenv("WAKE_REPEAT_MAGIC_SEC"); rs != "" {
+		if secs, err := time.ParseDuration(rs + "s"); err == nil {
+			wakeRepeatMagic = secs
+		}
+	}
+}
+
+// pollStrategy produces the delay to wait before the next isUp probe.
+type pollStrategy interface {
+	next() time.Duration
+}
+
+// newPollStrategy returns the fixed-interval strategy used historically
+// unless any WAKE_BACKOFF_* knob has been set, in which case it returns a
+// truncated exponential backoff with jitter (gRPC-style).
+func newPollStrategy() pollStrategy {
+	if !wakeBackoffEnabled {
+		return fixedInterval(pollInterval)
+	}
+	return &exponentialBackoff{
+		base:   wakeBackoffBase,
+		max:    wakeBackoffMax,
+		factor: wakeBackoffFactor,
+		jitter: wakeBackoffJitter,
+	}
+}
+
+type fixedInterval time.Duration
+
+func (f fixedInterval) next() time.Duration {
+	return time.Duration(f)
+}
+
+type exponentialBackoff struct {
+	base, max      time.Duration
+	factor, jitter float64
+	cur            time.Duration
+}
+
+func (b *exponentialBackoff) next() time.Duration {
+	if b.cur == 0 {
+		b.cur = b.base
+	} else {
+		b.cur = time.Duration(float64(b.cur) * b.factor)
+		if b.cur > b.max {
+			b.cur = b.max
+		}
+	}
+	return applyJitter(b.cur, b.jitter)
+}
+
+// applyJitter scales d by 1 + jitter*(rand*2-1), matching the gRPC
+// connection-backoff jitter formula.
+func applyJitter(d time.Duration, jitter float64) time.Duration {
+	if jitter <= 0 {
+		return d
+	}
+	delta := jitter * (rand.Float64()*2 - 1)
+	return time.Duration(float64(d) * (1 + delta))
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExponentialBackoffNext(t *testing.T) {
+	b := &exponentialBackoff{
+		base:   100 * time.Millisecond,
+		max:    1 * time.Second,
+		factor: 2,
+		jitter: 0,
+	}
+
+	want := []time.Duration{
+		100 * time.Millisecond,
+		200 * time.Millisecond,
+		400 * time.Millisecond,
+		800 * time.Millisecond,
+		1 * time.Second, // capped at max
+		1 * time.Second, // stays capped
+	}
+	for i, w := range want {
+		if got := b.next(); got != w {
+			t.Fatalf("next() call %d = %v, want %v", i, got, w)
+		}
+	}
+}
+
+func TestApplyJitterZeroIsNoop(t *testing.T) {
+	d := 500 * time.Millisecond
+	if got := applyJitter(d, 0); got != d {
+		t.Fatalf("applyJitter with jitter=0 = %v, want %v unchanged", got, d)
+	}
+}
+
+func TestApplyJitterBounds(t *testing.T) {
+	d := 1 * time.Second
+	jitter := 0.2
+	lo := time.Duration(float64(d) * (1 - jitter))
+	hi := time.Duration(float64(d) * (1 + jitter))
+
+	for i := 0; i < 100; i++ {
+		got := applyJitter(d, jitter)
+		if got < lo || got > hi {
+			t.Fatalf("applyJitter(%v, %v) = %v, want within [%v, %v]", d, jitter, got, lo, hi)
+		}
+	}
+}
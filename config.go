@@ -0,0 +1,205 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Device describes a single backend machine that can be woken on demand and
+// proxied to once it is online.
+type Device struct {
+	Name           string `yaml:"name" json:"name"`
+	MAC            string `yaml:"mac" json:"mac"`
+	IP             string `yaml:"ip" json:"ip"`
+	Port           string `yaml:"port" json:"port"`
+	BroadcastAddr  string `yaml:"broadcast_addr" json:"broadcast_addr"`
+	WakeTimeoutSec int    `yaml:"wake_timeout_sec" json:"wake_timeout_sec"`
+
+	// Host and PathPrefix are used by the Router to dispatch incoming
+	// requests to this device; either, both, or neither may be set.
+	Host       string `yaml:"host" json:"host"`
+	PathPrefix string `yaml:"path_prefix" json:"path_prefix"`
+
+	// Idle-shutdown settings; see sleep.go. ShutdownMethod is "ssh" or
+	// "http"; IdleShutdownSec of 0 disables auto-sleep for this device.
+	IdleShutdownSec    int    `yaml:"idle_shutdown_sec" json:"idle_shutdown_sec"`
+	ShutdownMethod     string `yaml:"shutdown_method" json:"shutdown_method"`
+	ShutdownSSHAddr    string `yaml:"shutdown_ssh_addr" json:"shutdown_ssh_addr"`
+	ShutdownSSHUser    string `yaml:"shutdown_ssh_user" json:"shutdown_ssh_user"`
+	ShutdownSSHCommand string `yaml:"shutdown_ssh_command" json:"shutdown_ssh_command"`
+	ShutdownURL        string `yaml:"shutdown_url" json:"shutdown_url"`
+
+	target       string
+	wakeTimeout  time.Duration
+	idleShutdown time.Duration
+}
+
+// Target returns the backend base URL for this device, e.g. "http://10.0.0.5:11434".
+func (d *Device) Target() string {
+	return d.target
+}
+
+// WakeTimeout returns how long the proxy should wait for this device to come
+// online before giving up.
+func (d *Device) WakeTimeout() time.Duration {
+	return d.wakeTimeout
+}
+
+// IdleShutdown returns how long the device may sit idle before the idle
+// monitor sends its shutdown command, or 0 if auto-sleep is disabled.
+func (d *Device) IdleShutdown() time.Duration {
+	return d.idleShutdown
+}
+
+// Config is the top-level shape of the CONFIG_FILE document.
+type Config struct {
+	ListenAddr string   `yaml:"listen_addr" json:"listen_addr"`
+	Devices    []Device `yaml:"devices" json:"devices"`
+}
+
+var (
+	listenAddr string
+	devices    []Device
+	router     *Router
+)
+
+// isKnownDevice reports whether name matches one of the configured devices.
+func isKnownDevice(name string) bool {
+	for i := range devices {
+		if devices[i].Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func initConfig() error {
+	listenAddr = os.Getenv("LISTEN_ADDR")
+	if listenAddr == "" {
+		listenAddr = "11434"
+	}
+
+	var cfg Config
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		loaded, err := loadConfigFile(path)
+		if err != nil {
+			return fmt.Errorf("loading CONFIG_FILE: %w", err)
+		}
+		cfg = *loaded
+	} else {
+		dev, err := deviceFromEnv()
+		if err != nil {
+			return err
+		}
+		cfg.Devices = []Device{*dev}
+	}
+
+	if cfg.ListenAddr != "" {
+		listenAddr = cfg.ListenAddr
+	}
+
+	if len(cfg.Devices) == 0 {
+		return errors.New("no devices configured: set CONFIG_FILE or DEVICE_MAC/DEVICE_IP/DEVICE_PORT")
+	}
+
+	for i := range cfg.Devices {
+		if err := finalizeDevice(&cfg.Devices[i]); err != nil {
+			return fmt.Errorf("device %q: %w", cfg.Devices[i].Name, err)
+		}
+	}
+
+	devices = cfg.Devices
+	router = newRouter(devices)
+
+	log.Printf("Configured %d device(s), LISTEN_ADDR=%s", len(devices), listenAddr)
+	return nil
+}
+
+// deviceFromEnv builds a single Device from the legacy DEVICE_MAC/DEVICE_IP/
+// DEVICE_PORT triple, preserved for users who haven't migrated to CONFIG_FILE.
+func deviceFromEnv() (*Device, error) {
+	mac := os.Getenv("DEVICE_MAC")
+	ip := os.Getenv("DEVICE_IP")
+	port := os.Getenv("DEVICE_PORT")
+	if mac == "" || ip == "" || port == "" {
+		return nil, errors.New("DEVICE_MAC, DEVICE_IP and DEVICE_PORT must be set")
+	}
+
+	dev := &Device{
+		Name: "default",
+		MAC:  mac,
+		IP:   ip,
+		Port: port,
+	}
+
+	if tw := os.Getenv("WAKE_TIMEOUT_SEC"); tw != "" {
+		secs, err := time.ParseDuration(tw + "s")
+		if err == nil {
+			dev.WakeTimeoutSec = int(secs.Seconds())
+		}
+	}
+
+	if is := os.Getenv("IDLE_SHUTDOWN_SEC"); is != "" {
+		secs, err := time.ParseDuration(is + "s")
+		if err == nil {
+			dev.IdleShutdownSec = int(secs.Seconds())
+		}
+	}
+	dev.ShutdownMethod = os.Getenv("SHUTDOWN_METHOD")
+	dev.ShutdownSSHAddr = os.Getenv("SHUTDOWN_SSH_ADDR")
+	dev.ShutdownSSHUser = os.Getenv("SHUTDOWN_SSH_USER")
+	dev.ShutdownSSHCommand = os.Getenv("SHUTDOWN_SSH_COMMAND")
+	dev.ShutdownURL = os.Getenv("SHUTDOWN_URL")
+
+	return dev, nil
+}
+
+func loadConfigFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg Config
+	if strings.HasSuffix(path, ".yaml") || strings.HasSuffix(path, ".yml") {
+		if err := yaml.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing yaml: %w", err)
+		}
+	} else {
+		if err := json.Unmarshal(data, &cfg); err != nil {
+			return nil, fmt.Errorf("parsing json: %w", err)
+		}
+	}
+
+	return &cfg, nil
+}
+
+func finalizeDevice(d *Device) error {
+	if d.Name == "" {
+		d.Name = d.IP
+	}
+	if d.MAC == "" || d.IP == "" || d.Port == "" {
+		return errors.New("mac, ip and port are required")
+	}
+
+	d.target = fmt.Sprintf("http://%s:%s", d.IP, d.Port)
+
+	if d.WakeTimeoutSec > 0 {
+		d.wakeTimeout = time.Duration(d.WakeTimeoutSec) * time.Second
+	} else {
+		d.wakeTimeout = 120 * time.Second
+	}
+
+	if d.IdleShutdownSec > 0 {
+		d.idleShutdown = time.Duration(d.IdleShutdownSec) * time.Second
+	}
+
+	return nil
+}
@@ -1,68 +1,37 @@
 package main
 
 import (
-	"errors"
-	"fmt"
 	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"os"
+	"strings"
+	"sync"
 	"time"
 )
 
 var (
-	deviceMAC    string
-	deviceIP     string
-	devicePort   string
-	target       string
-	listenAddr   string
-	pollInterval time.Duration
-	wakeTimeout  time.Duration
+	pollInterval        time.Duration
+	wakeStreamHeartbeat time.Duration
 )
 
-func initConfig() error {
-	deviceMAC = os.Getenv("DEVICE_MAC")
-	deviceIP = os.Getenv("DEVICE_IP")
-	devicePort = os.Getenv("DEVICE_PORT")
-	listenAddr = os.Getenv("LISTEN_ADDR")
-	if listenAddr == "" {
-		listenAddr = "11434"
-	}
-
-	if deviceMAC == "" || deviceIP == "" || devicePort == "" {
-		return errors.New("DEVICE_MAC, DEVICE_IP and DEVICE_PORT must be set")
-	}
-
-	target = fmt.Sprintf("http://%s:%s", deviceIP, devicePort)
-
+func init() {
 	pi := os.Getenv("POLL_INTERVAL_SEC")
 	if pi == "" {
 		pollInterval = 2 * time.Second
+	} else if secs, err := time.ParseDuration(pi + "s"); err != nil {
+		pollInterval = 2 * time.Second
 	} else {
-		secs, err := time.ParseDuration(pi + "s")
-		if err != nil {
-			pollInterval = 2 * time.Second
-		} else {
-			pollInterval = secs
-		}
+		pollInterval = secs
 	}
 
-	tw := os.Getenv("WAKE_TIMEOUT_SEC")
-	if tw == "" {
-		wakeTimeout = 120 * time.Second
-	} else {
-		secs, err := time.ParseDuration(tw + "s")
-		if err != nil {
-			wakeTimeout = 120 * time.Second
-		} else {
-			wakeTimeout = secs
+	if hb := os.Getenv("WAKE_STREAM_HEARTBEAT_SEC"); hb != "" {
+		if secs, err := time.ParseDuration(hb + "s"); err == nil {
+			wakeStreamHeartbeat = secs
 		}
 	}
-
-	log.Printf("Configured: DEVICE_MAC=%s DEVICE_IP=%s DEVICE_PORT=%s LISTEN_ADDR=%s", deviceMAC, deviceIP, devicePort, listenAddr)
-	return nil
 }
 
 func main() {
@@ -70,58 +39,141 @@ func main() {
 		log.Fatalf("config error: %v", err)
 	}
 
-	http.Handle("/", proxyHandler(target))
+	startIdleMonitor(devices)
+
+	http.Handle("/", proxyHandler(router))
+	http.HandleFunc("/status", statusHandler)
 
-	log.Printf("Starting proxy on %s -> %s", listenAddr, target)
+	log.Printf("Starting proxy on %s for %d device(s)", listenAddr, len(devices))
 	if err := http.ListenAndServe(":"+listenAddr, nil); err != nil {
 		log.Fatalf("server error: %v", err)
 	}
 }
 
-func proxyHandler(target string) http.Handler {
-	targetURL, _ := url.Parse(target)
-	proxy := httputil.NewSingleHostReverseProxy(targetURL)
+// stripPathPrefix removes device.PathPrefix from req's URL before it's
+// forwarded, so a device matched by e.g. "/gpu-b/" sees the same paths
+// ("/api/generate") that a Host- or header-routed device would, instead of
+// the prefix Ollama doesn't know about.
+func stripPathPrefix(req *http.Request, device *Device) {
+	if device.PathPrefix == "" || !strings.HasPrefix(req.URL.Path, device.PathPrefix) {
+		return
+	}
+
+	req.URL.Path = strings.TrimPrefix(req.URL.Path, device.PathPrefix)
+	if !strings.HasPrefix(req.URL.Path, "/") {
+		req.URL.Path = "/" + req.URL.Path
+	}
+	req.URL.RawPath = ""
+}
 
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if !ensureDeviceIsOnline(w, r) {
+// proxyHandler resolves the target device for each request via r, waking it
+// if necessary, and forwards the request through a cached reverse proxy.
+func proxyHandler(r *Router) http.Handler {
+	proxies := newProxyCache()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		device, err := r.Resolve(req)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		stripPathPrefix(req, device)
+
+		hb := newWakeHeartbeat(w, req)
+		defer hb.stop()
+		req = req.WithContext(withWakeHeartbeat(req.Context(), hb))
+
+		if !ensureDeviceIsOnline(w, req, device, hb) {
 			return
 		}
-		proxy.ServeHTTP(w, r)
+
+		recordActivity(device.Name)
+		proxies.get(device).ServeHTTP(w, req)
 	})
 }
 
-func ensureDeviceIsOnline(w http.ResponseWriter, r *http.Request) bool {
+// proxyCache lazily builds and reuses one *httputil.ReverseProxy per device.
+type proxyCache struct {
+	mu      sync.Mutex
+	proxies map[string]*httputil.ReverseProxy
+}
+
+func newProxyCache() *proxyCache {
+	return &proxyCache{proxies: make(map[string]*httputil.ReverseProxy)}
+}
+
+func (c *proxyCache) get(d *Device) *httputil.ReverseProxy {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if p, ok := c.proxies[d.Name]; ok {
+		return p
+	}
+
+	targetURL, _ := url.Parse(d.Target())
+	p := httputil.NewSingleHostReverseProxy(targetURL)
+	// Ollama streams NDJSON token-by-token; never let the proxy batch writes.
+	p.FlushInterval = -1
+	p.ModifyResponse = maskBackendStatusAfterHeartbeat
+	c.proxies[d.Name] = p
+	return p
+}
+
+// ensureDeviceIsOnline wakes device if needed and blocks until it responds
+// or the wait times out / the client disconnects. For streaming endpoints it
+// sends the client an early 200 plus periodic heartbeats while it waits, so
+// clients that time out on time-to-first-byte don't give up mid-wake.
+func ensureDeviceIsOnline(w http.ResponseWriter, r *http.Request, device *Device, hb *wakeHeartbeat) bool {
 	ctx := r.Context()
 
-	if !isUp(deviceIP, devicePort) {
-		log.Printf("device %s appears down; sending WoL", deviceIP)
-		if err := sendMagicPacket(deviceMAC); err != nil {
-			log.Printf("failed to send magic packet: %v", err)
-		} else {
-			log.Printf("magic packet sent to %s", deviceMAC)
-		}
+	if readinessProbe.Ready(device.IP, device.Port) {
+		return true
+	}
 
-		deadline := time.Now().Add(wakeTimeout)
-		for {
-			if isUp(deviceIP, devicePort) {
-				break
-			}
-			if time.Now().After(deadline) {
-				log.Printf("timeout waiting for device to come up")
+	log.Printf("device %s (%s) appears down; sending WoL", device.Name, device.IP)
+	if err := sendMagicPacket(device); err != nil {
+		log.Printf("failed to send magic packet: %v", err)
+	} else {
+		log.Printf("magic packet sent to %s", device.MAC)
+	}
+
+	poll := newPollStrategy()
+	lastMagic := time.Now()
+
+	deadline := time.Now().Add(device.WakeTimeout())
+	for {
+		if readinessProbe.Ready(device.IP, device.Port) {
+			return true
+		}
+		if time.Now().After(deadline) {
+			log.Printf("timeout waiting for device %s to come up", device.Name)
+			if !hb.started() {
 				http.Error(w, "timeout waiting for device to wake", http.StatusGatewayTimeout)
-				return false
 			}
-			select {
-			case <-ctx.Done():
-				log.Printf("request cancelled while waiting for device")
+			return false
+		}
+
+		if time.Since(lastMagic) >= wakeRepeatMagic {
+			if err := sendMagicPacket(device); err != nil {
+				log.Printf("failed to resend magic packet: %v", err)
+			} else {
+				log.Printf("resent magic packet to %s", device.MAC)
+			}
+			lastMagic = time.Now()
+		}
+
+		select {
+		case <-ctx.Done():
+			log.Printf("request cancelled while waiting for device %s", device.Name)
+			if !hb.started() {
 				http.Error(w, "client cancelled", http.StatusRequestTimeout)
-				return false
-			case <-time.After(pollInterval):
 			}
+			return false
+		case <-hb.tick():
+			hb.send(deadline)
+		case <-time.After(poll.next()):
 		}
 	}
-
-	return true
 }
 
 func isUp(ip, port string) bool {
@@ -134,39 +186,3 @@ func isUp(ip, port string) bool {
 	conn.Close()
 	return true
 }
-
-func sendMagicPacket(mac string) error {
-	hwAddr, err := net.ParseMAC(mac)
-	if err != nil {
-		return fmt.Errorf("invalid MAC address: %w", err)
-	}
-
-	// Build magic packet: 6x 0xFF followed by 16x MAC address
-	packet := make([]byte, 6+16*len(hwAddr))
-	for i := 0; i < 6; i++ {
-		packet[i] = 0xFF
-	}
-	for i := 6; i < len(packet); i += len(hwAddr) {
-		copy(packet[i:], hwAddr)
-	}
-
-	// Broadcast address + standard WOL UDP port 9
-	addr := &net.UDPAddr{
-		IP:   net.IPv4bcast,
-		Port: 9,
-	}
-
-	conn, err := net.DialUDP("udp", nil, addr)
-	if err != nil {
-		return fmt.Errorf("failed to dial UDP: %w", err)
-	}
-	defer conn.Close()
-
-	// Enable broadcast (needed on some systems)
-	if err := conn.SetWriteBuffer(len(packet)); err != nil {
-		return fmt.Errorf("failed to set write buffer: %w", err)
-	}
-
-	_, err = conn.Write(packet)
-	return err
-}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestStripPathPrefix(t *testing.T) {
+	device := &Device{Name: "gpu-b", PathPrefix: "/gpu-b"}
+	req := httptest.NewRequest(http.MethodGet, "/gpu-b/api/generate", nil)
+
+	stripPathPrefix(req, device)
+
+	if req.URL.Path != "/api/generate" {
+		t.Fatalf("URL.Path = %q, want /api/generate", req.URL.Path)
+	}
+}
+
+func TestStripPathPrefixNoMatch(t *testing.T) {
+	device := &Device{Name: "gpu-a", PathPrefix: "/gpu-a"}
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+
+	stripPathPrefix(req, device)
+
+	if req.URL.Path != "/api/generate" {
+		t.Fatalf("URL.Path = %q, want unchanged /api/generate", req.URL.Path)
+	}
+}
+
+func TestStripPathPrefixNoPrefixConfigured(t *testing.T) {
+	device := &Device{Name: "gpu-a"}
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+
+	stripPathPrefix(req, device)
+
+	if req.URL.Path != "/api/generate" {
+		t.Fatalf("URL.Path = %q, want unchanged /api/generate", req.URL.Path)
+	}
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"golang.org/x/net/icmp"
+	"golang.org/x/net/ipv4"
+)
+
+// probeTimeout bounds every readiness check, independent of the overall
+// wake timeout.
+const probeTimeout = 2 * time.Second
+
+// Probe decides whether a device is ready to receive proxied traffic. The
+// default (and historical) behavior is a raw TCP dial, which is often too
+// optimistic for services like Ollama that accept connections before a
+// model is loaded.
+type Probe interface {
+	Ready(ip, port string) bool
+}
+
+var readinessProbe Probe
+
+func init() {
+	path := os.Getenv("READINESS_PATH")
+	if path == "" {
+		path = "/"
+	}
+
+	expectStatus := 0 // 0 means "any 2xx"
+	if es := os.Getenv("READINESS_EXPECT_STATUS"); es != "" {
+		if v, err := strconv.Atoi(es); err == nil {
+			expectStatus = v
+		}
+	}
+
+	switch os.Getenv("READINESS_PROBE") {
+	case "http":
+		readinessProbe = &httpProbe{path: path, expectStatus: expectStatus}
+	case "ollama":
+		readinessProbe = ollamaProbe{}
+	case "icmp":
+		readinessProbe = icmpProbe{}
+	default:
+		readinessProbe = tcpProbe{}
+	}
+}
+
+// tcpProbe is the original behavior: ready as soon as the OS network stack
+// accepts a connection on the port.
+type tcpProbe struct{}
+
+func (tcpProbe) Ready(ip, port string) bool {
+	return isUp(ip, port)
+}
+
+// httpProbe GETs path and expects a 2xx (or expectStatus, if set).
+type httpProbe struct {
+	path         string
+	expectStatus int
+}
+
+func (p *httpProbe) Ready(ip, port string) bool {
+	client := http.Client{Timeout: probeTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s%s", net.JoinHostPort(ip, port), p.path))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if p.expectStatus != 0 {
+		return resp.StatusCode == p.expectStatus
+	}
+	return resp.StatusCode >= 200 && resp.StatusCode < 300
+}
+
+// ollamaProbe GETs /api/tags and checks the body is valid JSON, which only
+// happens once Ollama itself (not just the port) is serving requests.
+type ollamaProbe struct{}
+
+func (ollamaProbe) Ready(ip, port string) bool {
+	client := http.Client{Timeout: probeTimeout}
+	resp, err := client.Get(fmt.Sprintf("http://%s/api/tags", net.JoinHostPort(ip, port)))
+	if err != nil {
+		return false
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false
+	}
+	var v interface{}
+	return json.NewDecoder(resp.Body).Decode(&v) == nil
+}
+
+// icmpProbe pings the host directly, for devices where the target port
+// isn't a meaningful liveness signal.
+type icmpProbe struct{}
+
+func (icmpProbe) Ready(ip, port string) bool {
+	conn, err := icmp.ListenPacket("ip4:icmp", "0.0.0.0")
+	if err != nil {
+		return false
+	}
+	defer conn.Close()
+
+	msg := icmp.Message{
+		Type: ipv4.ICMPTypeEcho,
+		Code: 0,
+		Body: &icmp.Echo{
+			ID:   os.Getpid() & 0xffff,
+			Seq:  1,
+			Data: []byte("wake-ollama"),
+		},
+	}
+	b, err := msg.Marshal(nil)
+	if err != nil {
+		return false
+	}
+
+	dst, err := net.ResolveIPAddr("ip4", ip)
+	if err != nil {
+		return false
+	}
+
+	conn.SetDeadline(time.Now().Add(probeTimeout))
+	if _, err := conn.WriteTo(b, dst); err != nil {
+		return false
+	}
+
+	reply := make([]byte, 1500)
+	n, _, err := conn.ReadFrom(reply)
+	if err != nil {
+		return false
+	}
+
+	parsed, err := icmp.ParseMessage(1, reply[:n])
+	if err != nil {
+		return false
+	}
+	return parsed.Type == ipv4.ICMPTypeEchoReply
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// wakeTargetHeader lets a client explicitly pick a device by name,
+// bypassing Host/path matching.
+const wakeTargetHeader = "X-Wake-Target"
+
+// Router selects the Device that should handle a given request, based on
+// (in priority order) the X-Wake-Target header, the Host header, and the
+// URL path prefix.
+type Router struct {
+	devices  []Device
+	byName   map[string]*Device
+	byHost   map[string]*Device
+	byPrefix []*Device
+	fallback *Device
+}
+
+func newRouter(devices []Device) *Router {
+	r := &Router{
+		devices: devices,
+		byName:  make(map[string]*Device),
+		byHost:  make(map[string]*Device),
+	}
+
+	for i := range devices {
+		d := &devices[i]
+		r.byName[d.Name] = d
+		if d.Host != "" {
+			r.byHost[d.Host] = d
+		}
+		if d.PathPrefix != "" {
+			r.byPrefix = append(r.byPrefix, d)
+		}
+	}
+
+	if len(devices) == 1 {
+		r.fallback = &devices[0]
+	}
+
+	return r
+}
+
+// Resolve returns the Device that should serve req.
+func (r *Router) Resolve(req *http.Request) (*Device, error) {
+	if name := req.Header.Get(wakeTargetHeader); name != "" {
+		if d, ok := r.byName[name]; ok {
+			return d, nil
+		}
+		return nil, fmt.Errorf("unknown %s: %q", wakeTargetHeader, name)
+	}
+
+	if host := req.Host; host != "" {
+		if d, ok := r.byHost[stripPort(host)]; ok {
+			return d, nil
+		}
+	}
+
+	for _, d := range r.byPrefix {
+		if strings.HasPrefix(req.URL.Path, d.PathPrefix) {
+			return d, nil
+		}
+	}
+
+	if r.fallback != nil {
+		return r.fallback, nil
+	}
+
+	return nil, fmt.Errorf("no device matches host %q path %q", req.Host, req.URL.Path)
+}
+
+func stripPort(host string) string {
+	if i := strings.IndexByte(host, ':'); i != -1 {
+		return host[:i]
+	}
+	return host
+}
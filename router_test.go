@@ -0,0 +1,89 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func testDevices() []Device {
+	return []Device{
+		{Name: "gpu-a", MAC: "00:11:22:33:44:55", IP: "10.0.0.1", Port: "11434", Host: "gpu-a.lan"},
+		{Name: "gpu-b", MAC: "00:11:22:33:44:66", IP: "10.0.0.2", Port: "11434", PathPrefix: "/gpu-b/"},
+	}
+}
+
+func TestRouterResolveByHeader(t *testing.T) {
+	r := newRouter(testDevices())
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+	req.Header.Set(wakeTargetHeader, "gpu-b")
+
+	d, err := r.Resolve(req)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if d.Name != "gpu-b" {
+		t.Fatalf("resolved %q, want gpu-b", d.Name)
+	}
+}
+
+func TestRouterResolveByHost(t *testing.T) {
+	r := newRouter(testDevices())
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+	req.Host = "gpu-a.lan:11434"
+
+	d, err := r.Resolve(req)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if d.Name != "gpu-a" {
+		t.Fatalf("resolved %q, want gpu-a", d.Name)
+	}
+}
+
+func TestRouterResolveByPathPrefix(t *testing.T) {
+	r := newRouter(testDevices())
+	req := httptest.NewRequest(http.MethodGet, "/gpu-b/api/generate", nil)
+
+	d, err := r.Resolve(req)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if d.Name != "gpu-b" {
+		t.Fatalf("resolved %q, want gpu-b", d.Name)
+	}
+}
+
+func TestRouterResolveUnknownHeader(t *testing.T) {
+	r := newRouter(testDevices())
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+	req.Header.Set(wakeTargetHeader, "does-not-exist")
+
+	if _, err := r.Resolve(req); err == nil {
+		t.Fatal("expected error for unknown X-Wake-Target, got nil")
+	}
+}
+
+func TestRouterResolveNoMatchNoFallback(t *testing.T) {
+	r := newRouter(testDevices())
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+	req.Host = "unrelated.example"
+
+	if _, err := r.Resolve(req); err == nil {
+		t.Fatal("expected error when nothing matches and there's more than one device, got nil")
+	}
+}
+
+func TestRouterResolveSingleDeviceFallback(t *testing.T) {
+	r := newRouter([]Device{{Name: "only", MAC: "00:11:22:33:44:55", IP: "10.0.0.1", Port: "11434"}})
+	req := httptest.NewRequest(http.MethodGet, "/api/generate", nil)
+	req.Host = "unrelated.example"
+
+	d, err := r.Resolve(req)
+	if err != nil {
+		t.Fatalf("Resolve: %v", err)
+	}
+	if d.Name != "only" {
+		t.Fatalf("resolved %q, want only", d.Name)
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// idleCheckInterval is how often the idle monitor re-evaluates every
+// device's last-activity timestamp.
+const idleCheckInterval = 5 * time.Second
+
+// activityState tracks the idle-shutdown bookkeeping for a single device.
+type activityState struct {
+	mu           sync.Mutex
+	lastActivity time.Time
+	inhibitUntil time.Time
+}
+
+var activity = struct {
+	mu sync.Mutex
+	m  map[string]*activityState
+}{m: make(map[string]*activityState)}
+
+func activityFor(name string) *activityState {
+	activity.mu.Lock()
+	defer activity.mu.Unlock()
+
+	s, ok := activity.m[name]
+	if !ok {
+		s = &activityState{lastActivity: time.Now()}
+		activity.m[name] = s
+	}
+	return s
+}
+
+// recordActivity marks device as having just served a request, resetting its
+// idle clock.
+func recordActivity(name string) {
+	s := activityFor(name)
+	s.mu.Lock()
+	s.lastActivity = time.Now()
+	s.mu.Unlock()
+}
+
+// inhibitSleep blocks the idle monitor from shutting device down until d has
+// elapsed.
+func inhibitSleep(name string, d time.Duration) {
+	s := activityFor(name)
+	s.mu.Lock()
+	s.inhibitUntil = time.Now().Add(d)
+	s.mu.Unlock()
+}
+
+// startIdleMonitor launches the background goroutine that shuts devices down
+// after they've been idle for longer than their IdleShutdown.
+func startIdleMonitor(devices []Device) {
+	active := false
+	for i := range devices {
+		if devices[i].IdleShutdown() > 0 {
+			active = true
+		}
+		activityFor(devices[i].Name)
+	}
+	if !active {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(idleCheckInterval)
+		defer ticker.Stop()
+		for range ticker.C {
+			for i := range devices {
+				checkIdle(&devices[i])
+			}
+		}
+	}()
+}
+
+func checkIdle(d *Device) {
+	idle := d.IdleShutdown()
+	if idle <= 0 {
+		return
+	}
+
+	s := activityFor(d.Name)
+	s.mu.Lock()
+	sinceActivity := time.Since(s.lastActivity)
+	inhibited := time.Now().Before(s.inhibitUntil)
+	s.mu.Unlock()
+
+	if inhibited || sinceActivity < idle {
+		return
+	}
+
+	if !readinessProbe.Ready(d.IP, d.Port) {
+		return
+	}
+
+	log.Printf("device %s idle for %s; sending shutdown", d.Name, sinceActivity.Round(time.Second))
+	if err := shutdownDevice(d); err != nil {
+		log.Printf("failed to shut down device %s: %v", d.Name, err)
+		return
+	}
+
+	// Reset the clock so we don't resend the shutdown command every tick
+	// while the device powers off.
+	recordActivity(d.Name)
+}
+
+func shutdownDevice(d *Device) error {
+	switch d.ShutdownMethod {
+	case "http":
+		return shutdownViaHTTP(d)
+	case "ssh", "":
+		return shutdownViaSSH(d)
+	default:
+		return fmt.Errorf("unknown shutdown_method %q", d.ShutdownMethod)
+	}
+}
+
+func shutdownViaHTTP(d *Device) error {
+	if d.ShutdownURL == "" {
+		return fmt.Errorf("shutdown_url not configured")
+	}
+
+	resp, err := http.Post(d.ShutdownURL, "application/json", nil)
+	if err != nil {
+		return fmt.Errorf("POST %s: %w", d.ShutdownURL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("POST %s: unexpected status %s", d.ShutdownURL, resp.Status)
+	}
+	return nil
+}
+
+func shutdownViaSSH(d *Device) error {
+	keyPath := os.Getenv("SSH_KEY_PATH")
+	if keyPath == "" {
+		return fmt.Errorf("SSH_KEY_PATH not set")
+	}
+	if d.ShutdownSSHCommand == "" {
+		return fmt.Errorf("shutdown_ssh_command not configured")
+	}
+
+	key, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("reading SSH_KEY_PATH: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(key)
+	if err != nil {
+		return fmt.Errorf("parsing private key: %w", err)
+	}
+
+	addr := d.ShutdownSSHAddr
+	if addr == "" {
+		addr = net.JoinHostPort(d.IP, "22")
+	}
+	user := d.ShutdownSSHUser
+	if user == "" {
+		user = "root"
+	}
+
+	client, err := ssh.Dial("tcp", addr, &ssh.ClientConfig{
+		User:            user,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		Timeout:         5 * time.Second,
+	})
+	if err != nil {
+		return fmt.Errorf("dialing %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("opening session: %w", err)
+	}
+	defer session.Close()
+
+	return session.Run(d.ShutdownSSHCommand)
+}
+
+// statusHandler serves GET /status with each device's idle-shutdown state,
+// and accepts POST /status?device=NAME&inhibit_sec=N to temporarily block
+// auto-sleep for that device.
+func statusHandler(w http.ResponseWriter, r *http.Request) {
+	if r.Method == http.MethodPost {
+		name := r.URL.Query().Get("device")
+		secs, err := strconv.Atoi(r.URL.Query().Get("inhibit_sec"))
+		if name == "" || err != nil {
+			http.Error(w, "usage: POST /status?device=NAME&inhibit_sec=N", http.StatusBadRequest)
+			return
+		}
+		if !isKnownDevice(name) {
+			http.Error(w, fmt.Sprintf("unknown device %q", name), http.StatusNotFound)
+			return
+		}
+		inhibitSleep(name, time.Duration(secs)*time.Second)
+	}
+
+	type deviceStatus struct {
+		Name              string `json:"name"`
+		LastActivity      string `json:"last_activity"`
+		SleepInhibitUntil string `json:"sleep_inhibit_until,omitempty"`
+	}
+
+	var out []deviceStatus
+	for i := range devices {
+		s := activityFor(devices[i].Name)
+		s.mu.Lock()
+		ds := deviceStatus{
+			Name:         devices[i].Name,
+			LastActivity: s.lastActivity.UTC().Format(time.RFC3339),
+		}
+		if time.Now().Before(s.inhibitUntil) {
+			ds.SleepInhibitUntil = s.inhibitUntil.UTC().Format(time.RFC3339)
+		}
+		s.mu.Unlock()
+		out = append(out, ds)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(out)
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// streamingEndpoints are the Ollama routes that return a long-lived NDJSON
+// stream, where silently buffering the wake wait would starve the client's
+// time-to-first-byte timeout.
+var streamingEndpoints = map[string]bool{
+	"/api/generate": true,
+	"/api/chat":     true,
+}
+
+func isStreamingEndpoint(path string) bool {
+	return streamingEndpoints[path]
+}
+
+// wakeHeartbeat writes periodic "waking" keep-alive lines to a streaming
+// client while ensureDeviceIsOnline waits for the device to come up. It is a
+// no-op for non-streaming requests, requests on a ResponseWriter that can't
+// flush, or when WAKE_STREAM_HEARTBEAT_SEC is unset.
+type wakeHeartbeat struct {
+	w        http.ResponseWriter
+	flusher  http.Flusher
+	enabled  bool
+	sentHead bool
+	ticker   *time.Ticker
+}
+
+func newWakeHeartbeat(w http.ResponseWriter, r *http.Request) *wakeHeartbeat {
+	hb := &wakeHeartbeat{w: w}
+
+	if wakeStreamHeartbeat <= 0 || !isStreamingEndpoint(r.URL.Path) {
+		return hb
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		return hb
+	}
+
+	hb.flusher = flusher
+	hb.enabled = true
+	// A persistent ticker, not a fresh time.After per select iteration, so
+	// heartbeats accumulate on their own cadence independent of how often
+	// the poll loop wakes up.
+	hb.ticker = time.NewTicker(wakeStreamHeartbeat)
+	return hb
+}
+
+// tick returns the channel to select on for the next heartbeat, or nil
+// (which blocks forever, as select ignores it) when heartbeats are disabled.
+func (hb *wakeHeartbeat) tick() <-chan time.Time {
+	if !hb.enabled {
+		return nil
+	}
+	return hb.ticker.C
+}
+
+// stop releases the heartbeat ticker's resources. Safe to call even when
+// heartbeats are disabled.
+func (hb *wakeHeartbeat) stop() {
+	if hb.ticker != nil {
+		hb.ticker.Stop()
+	}
+}
+
+func (hb *wakeHeartbeat) send(deadline time.Time) {
+	if !hb.sentHead {
+		hb.w.WriteHeader(http.StatusOK)
+		hb.sentHead = true
+	}
+
+	eta := int(time.Until(deadline).Seconds())
+	if eta < 0 {
+		eta = 0
+	}
+	fmt.Fprintf(hb.w, "{\"status\":\"waking\",\"eta_sec\":%d}\n", eta)
+	hb.flusher.Flush()
+}
+
+// started reports whether a heartbeat response has already been committed to
+// the client, meaning it's too late to write an error status instead.
+func (hb *wakeHeartbeat) started() bool {
+	return hb.sentHead
+}
+
+type wakeHeartbeatCtxKey struct{}
+
+// withWakeHeartbeat attaches hb to ctx so the reverse proxy's ModifyResponse
+// hook can tell, once the backend has actually responded, whether a 200 was
+// already committed to the client during the wake wait.
+func withWakeHeartbeat(ctx context.Context, hb *wakeHeartbeat) context.Context {
+	return context.WithValue(ctx, wakeHeartbeatCtxKey{}, hb)
+}
+
+func wakeHeartbeatFromContext(ctx context.Context) *wakeHeartbeat {
+	hb, _ := ctx.Value(wakeHeartbeatCtxKey{}).(*wakeHeartbeat)
+	return hb
+}
+
+// maskBackendStatusAfterHeartbeat is a ReverseProxy.ModifyResponse hook. If a
+// wake heartbeat already committed a 200 to the client, the real backend
+// status can no longer be sent (net/http drops a second WriteHeader as
+// superfluous), so a non-2xx backend response would otherwise be silently
+// relabeled as a successful 200 stream. Detect that case and replace the
+// body with an explicit error marker instead of the backend's real body.
+func maskBackendStatusAfterHeartbeat(resp *http.Response) error {
+	hb := wakeHeartbeatFromContext(resp.Request.Context())
+	if hb == nil || !hb.started() {
+		return nil
+	}
+	if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+		return nil
+	}
+
+	log.Printf("backend returned %d after a wake heartbeat committed 200; masking body", resp.StatusCode)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(strings.NewReader(fmt.Sprintf("{\"status\":\"error\",\"backend_status\":%d}\n", resp.StatusCode)))
+	resp.ContentLength = -1
+	resp.Header.Del("Content-Length")
+	return nil
+}
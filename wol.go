@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+var (
+	wolPort      string
+	wolInterface string
+	wolPassword  []byte
+	wolBroadcast []string
+)
+
+func init() {
+	wolPort = os.Getenv("WOL_PORT")
+	if wolPort == "" {
+		wolPort = "9"
+	}
+
+	wolInterface = os.Getenv("WOL_INTERFACE")
+
+	if pw := os.Getenv("WOL_PASSWORD"); pw != "" {
+		if b, err := parseSecureONPassword(pw); err != nil {
+			log.Printf("ignoring WOL_PASSWORD: %v", err)
+		} else {
+			wolPassword = b
+		}
+	}
+
+	if addrs := os.Getenv("WOL_BROADCAST_ADDR"); addrs != "" {
+		wolBroadcast = splitAddrs(addrs)
+	} else {
+		wolBroadcast = []string{"255.255.255.255"}
+	}
+}
+
+// parseSecureONPassword accepts either a dotted-decimal (1.2.3.4.5.6) or
+// hex (AABBCCDDEEFF) SecureON password and returns its 6 raw bytes.
+func parseSecureONPassword(pw string) ([]byte, error) {
+	if strings.Contains(pw, ".") {
+		parts := strings.Split(pw, ".")
+		if len(parts) != 6 {
+			return nil, fmt.Errorf("invalid SecureON password %q: must have 6 dotted components", pw)
+		}
+		b := make([]byte, 6)
+		for i, p := range parts {
+			v, err := strconv.Atoi(p)
+			if err != nil || v < 0 || v > 255 {
+				return nil, fmt.Errorf("invalid SecureON password %q: must have 6 dotted components", pw)
+			}
+			b[i] = byte(v)
+		}
+		return b, nil
+	}
+
+	b, err := hex.DecodeString(strings.ReplaceAll(pw, ":", ""))
+	if err != nil || len(b) != 6 {
+		return nil, fmt.Errorf("invalid SecureON password %q: must be 6 bytes", pw)
+	}
+	return b, nil
+}
+
+func splitAddrs(s string) []string {
+	var out []string
+	for _, a := range strings.Split(s, ",") {
+		if a = strings.TrimSpace(a); a != "" {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// broadcastAddrsFor returns the broadcast addresses a magic packet should be
+// sent to for d: its own BroadcastAddr if set (comma-separated for multiple,
+// e.g. a LAN broadcast plus a VLAN sub-broadcast), else the WOL_BROADCAST_ADDR
+// default.
+func broadcastAddrsFor(d *Device) []string {
+	if d.BroadcastAddr != "" {
+		return splitAddrs(d.BroadcastAddr)
+	}
+	return wolBroadcast
+}
+
+// wolSocket opens the UDP socket magic packets are sent from, bound to
+// WOL_INTERFACE's address when set so packets don't egress the wrong NIC.
+// SO_BROADCAST is set on the socket, since the kernel otherwise refuses
+// (EACCES) to send to a broadcast address, directed or not.
+func wolSocket() (*net.UDPConn, error) {
+	conn, err := wolListen()
+	if err != nil {
+		return nil, err
+	}
+	if err := setBroadcast(conn); err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("setting SO_BROADCAST: %w", err)
+	}
+	return conn, nil
+}
+
+func wolListen() (*net.UDPConn, error) {
+	if wolInterface == "" {
+		return net.ListenUDP("udp", nil)
+	}
+
+	iface, err := net.InterfaceByName(wolInterface)
+	if err != nil {
+		return nil, fmt.Errorf("WOL_INTERFACE %q: %w", wolInterface, err)
+	}
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return nil, fmt.Errorf("WOL_INTERFACE %q: %w", wolInterface, err)
+	}
+
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok || ipNet.IP.To4() == nil {
+			continue
+		}
+		return net.ListenUDP("udp", &net.UDPAddr{IP: ipNet.IP})
+	}
+	return nil, fmt.Errorf("WOL_INTERFACE %q has no IPv4 address", wolInterface)
+}
+
+// setBroadcast enables SO_BROADCAST on conn so writes to broadcast
+// addresses aren't rejected by the kernel.
+func setBroadcast(conn *net.UDPConn) error {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	var sockErr error
+	if err := raw.Control(func(fd uintptr) {
+		sockErr = syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_BROADCAST, 1)
+	}); err != nil {
+		return err
+	}
+	return sockErr
+}
+
+// buildMagicPacket builds the 6x 0xFF + 16x MAC payload, with the optional
+// SecureON password appended.
+func buildMagicPacket(mac string) ([]byte, error) {
+	hwAddr, err := net.ParseMAC(mac)
+	if err != nil {
+		return nil, fmt.Errorf("invalid MAC address: %w", err)
+	}
+
+	packet := make([]byte, 6+16*len(hwAddr)+len(wolPassword))
+	for i := 0; i < 6; i++ {
+		packet[i] = 0xFF
+	}
+	for i := 6; i < 6+16*len(hwAddr); i += len(hwAddr) {
+		copy(packet[i:], hwAddr)
+	}
+	copy(packet[6+16*len(hwAddr):], wolPassword)
+
+	return packet, nil
+}
+
+// sendMagicPacket wakes device by sending a magic packet to each of its
+// configured broadcast addresses.
+func sendMagicPacket(device *Device) error {
+	packet, err := buildMagicPacket(device.MAC)
+	if err != nil {
+		return err
+	}
+
+	conn, err := wolSocket()
+	if err != nil {
+		return fmt.Errorf("opening WoL socket: %w", err)
+	}
+	defer conn.Close()
+
+	var errs []string
+	for _, broadcast := range broadcastAddrsFor(device) {
+		addr, err := net.ResolveUDPAddr("udp", net.JoinHostPort(broadcast, wolPort))
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", broadcast, err))
+			continue
+		}
+		if _, err := conn.WriteToUDP(packet, addr); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", broadcast, err))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("sending magic packet: %s", strings.Join(errs, "; "))
+	}
+	return nil
+}
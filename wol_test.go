@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestBuildMagicPacket(t *testing.T) {
+	oldPassword := wolPassword
+	defer func() { wolPassword = oldPassword }()
+
+	wolPassword = nil
+	packet, err := buildMagicPacket("00:11:22:33:44:55")
+	if err != nil {
+		t.Fatalf("buildMagicPacket: %v", err)
+	}
+	if len(packet) != 6+16*6 {
+		t.Fatalf("packet length = %d, want %d", len(packet), 6+16*6)
+	}
+	if !bytes.Equal(packet[:6], []byte{0xFF, 0xFF, 0xFF, 0xFF, 0xFF, 0xFF}) {
+		t.Fatalf("packet header = % x, want 6x 0xFF", packet[:6])
+	}
+	mac := []byte{0x00, 0x11, 0x22, 0x33, 0x44, 0x55}
+	for i := 6; i < len(packet); i += 6 {
+		if !bytes.Equal(packet[i:i+6], mac) {
+			t.Fatalf("repetition at %d = % x, want % x", i, packet[i:i+6], mac)
+		}
+	}
+}
+
+func TestBuildMagicPacketWithPassword(t *testing.T) {
+	oldPassword := wolPassword
+	defer func() { wolPassword = oldPassword }()
+
+	wolPassword = []byte{1, 2, 3, 4, 5, 6}
+	packet, err := buildMagicPacket("00:11:22:33:44:55")
+	if err != nil {
+		t.Fatalf("buildMagicPacket: %v", err)
+	}
+	if len(packet) != 6+16*6+6 {
+		t.Fatalf("packet length = %d, want %d", len(packet), 6+16*6+6)
+	}
+	if !bytes.Equal(packet[len(packet)-6:], wolPassword) {
+		t.Fatalf("trailing password = % x, want % x", packet[len(packet)-6:], wolPassword)
+	}
+}
+
+func TestBuildMagicPacketInvalidMAC(t *testing.T) {
+	if _, err := buildMagicPacket("not-a-mac"); err == nil {
+		t.Fatal("expected error for invalid MAC, got nil")
+	}
+}
+
+func TestParseSecureONPassword(t *testing.T) {
+	want := []byte{0x01, 0x02, 0x03, 0x04, 0x05, 0x06}
+
+	dotted, err := parseSecureONPassword("1.2.3.4.5.6")
+	if err != nil {
+		t.Fatalf("dotted-decimal: %v", err)
+	}
+	if !bytes.Equal(dotted, want) {
+		t.Fatalf("dotted-decimal = % x, want % x", dotted, want)
+	}
+
+	hex, err := parseSecureONPassword("010203040506")
+	if err != nil {
+		t.Fatalf("hex: %v", err)
+	}
+	if !bytes.Equal(hex, want) {
+		t.Fatalf("hex = % x, want % x", hex, want)
+	}
+}
+
+func TestParseSecureONPasswordInvalid(t *testing.T) {
+	if _, err := parseSecureONPassword("not-valid"); err == nil {
+		t.Fatal("expected error for invalid password, got nil")
+	}
+}